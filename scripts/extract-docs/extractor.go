@@ -183,10 +183,149 @@ func writeFile(outputDir, relPath, content string) error {
 	return os.WriteFile(full, []byte(content), 0o644)
 }
 
-// copyTreeWithSubstitution walks src and copies every file to dst, applying
-// module-path substitution to text content. It does not follow symlinks.
-func copyTreeWithSubstitution(src, dst, targetModule string) error {
-	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+// Collision strategies, applied when two sources want the same path — a
+// templates/ file and an extracted doc file, or a regenerated file and a
+// hand-written one already in the output.
+const (
+	collisionError  = "error"  // refuse to write anything
+	collisionSkip   = "skip"   // keep what's there, drop the new content
+	collisionRename = "rename" // write the new content beside it as <path>.new
+	collisionMerge  = "merge"  // append the new content to what's there
+)
+
+// renameSuffix is appended to a path under collisionRename.
+const renameSuffix = ".new"
+
+// validCollisionStrategy reports whether s names a known collision strategy.
+func validCollisionStrategy(s string) bool {
+	switch s {
+	case collisionError, collisionSkip, collisionRename, collisionMerge:
+		return true
+	}
+	return false
+}
+
+// Markers bracketing the generated region of every file the extractor writes.
+// Regeneration with --force rewrites only what sits between them; anything a
+// maintainer added outside the region survives.
+const (
+	regionBegin = "extract-docs:begin"
+	regionEnd   = "extract-docs:end"
+)
+
+// commentPrefix returns the line-comment token for relPath's file type, or ""
+// when the extractor doesn't know one — such files can't carry a generated
+// region and are always treated as a whole. A renamed collision (<path>.new)
+// takes its original's syntax, so it can be moved back in place.
+func commentPrefix(relPath string) string {
+	base := strings.TrimSuffix(filepath.Base(relPath), renameSuffix)
+	switch ext := filepath.Ext(base); {
+	case ext == ".go":
+		return "//"
+	case ext == ".sql":
+		return "--"
+	case ext == ".yml", ext == ".yaml", ext == ".toml", ext == ".sh",
+		base == "Makefile", strings.HasPrefix(base, ".env"):
+		return "#"
+	}
+	return ""
+}
+
+// markRegion wraps content in the generated-region markers for relPath's
+// comment syntax. Content of an unknown file type is returned unchanged.
+func markRegion(relPath, content string) string {
+	prefix := commentPrefix(relPath)
+	if prefix == "" {
+		return content
+	}
+	return prefix + " " + regionBegin + "\n\n" + strings.TrimRight(content, "\n") + "\n\n" + prefix + " " + regionEnd + "\n"
+}
+
+// replaceRegion swaps the generated region of existing for content. ok is
+// false when existing has no region; a half-open or repeated region is an
+// error rather than a guess.
+func replaceRegion(relPath, existing, content string) (out string, ok bool, err error) {
+	prefix := commentPrefix(relPath)
+	if prefix == "" {
+		return "", false, nil
+	}
+	lines := strings.SplitAfter(existing, "\n")
+	begin, end := -1, -1
+	for i, line := range lines {
+		switch strings.TrimSpace(line) {
+		case prefix + " " + regionBegin:
+			if begin >= 0 {
+				return "", false, fmt.Errorf("%s: more than one generated region", relPath)
+			}
+			begin = i
+		case prefix + " " + regionEnd:
+			if begin < 0 || end >= 0 {
+				return "", false, fmt.Errorf("%s: unbalanced generated region markers", relPath)
+			}
+			end = i
+		}
+	}
+	if begin < 0 && end < 0 {
+		return "", false, nil
+	}
+	if begin < 0 || end < 0 {
+		return "", false, fmt.Errorf("%s: unbalanced generated region markers", relPath)
+	}
+	return strings.Join(lines[:begin], "") + markRegion(relPath, content) + strings.Join(lines[end+1:], ""), true, nil
+}
+
+// mergeContent appends content to existing as its own generated region. Go
+// files are refused: a second package clause and import block can't follow
+// declarations, so the result would never compile.
+func mergeContent(relPath, existing, content string) (string, error) {
+	if filepath.Ext(relPath) == ".go" {
+		return "", fmt.Errorf("%s: cannot merge Go files (use --collisions=rename and merge by hand)", relPath)
+	}
+	return strings.TrimRight(existing, "\n") + "\n\n" + markRegion(relPath, content), nil
+}
+
+// resolveTemplates folds the templates/ files into files, the extracted doc
+// content keyed by path. A template whose path matches an extracted file is
+// resolved with strategy; under collisionError nothing is folded and the
+// clashing paths come back sorted.
+func resolveTemplates(files, templates map[string]string, strategy string) ([]string, error) {
+	var clashes []string
+	for path := range templates {
+		if _, ok := files[path]; ok {
+			clashes = append(clashes, path)
+		}
+	}
+	sort.Strings(clashes)
+	if len(clashes) > 0 && strategy == collisionError {
+		return clashes, nil
+	}
+
+	for path, content := range templates {
+		existing, ok := files[path]
+		if !ok {
+			files[path] = content
+			continue
+		}
+		switch strategy {
+		case collisionRename:
+			files[path+renameSuffix] = content
+		case collisionMerge:
+			merged, err := mergeContent(path, existing, content)
+			if err != nil {
+				return nil, err
+			}
+			files[path] = merged
+		}
+	}
+	return nil, nil
+}
+
+// readTree reads every file under src into a map keyed by slash-separated
+// relative path, applying module-path substitution. It does not follow
+// symlinks.
+func readTree(src, targetModule string) (map[string]string, error) {
+	out := make(map[string]string)
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -201,30 +340,91 @@ func copyTreeWithSubstitution(src, dst, targetModule string) error {
 		if err != nil {
 			return err
 		}
-		out := substituteModulePath(string(data), targetModule)
-		return writeFile(dst, rel, out)
+		out[filepath.ToSlash(rel)] = substituteModulePath(string(data), targetModule)
+		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
 
-// renderGoModTemplate reads go.mod.tmpl and writes go.mod with the target
+// regenerate plans an in-place rewrite of files into the existing outputDir.
+// Missing files are created, generated regions are replaced, and a file with
+// no region whose content differs is a collision resolved with strategy. It
+// returns the writes to perform (keyed by relative path) and, under
+// collisionError, the clashing paths — in which case nothing should be
+// written. Notes on skipped files go to log.
+func regenerate(outputDir string, files map[string]string, strategy string, log io.Writer) (map[string]string, []string, error) {
+	writes := make(map[string]string)
+	var clashes []string
+	for _, path := range sortedKeys(files) {
+		content := files[path]
+		data, err := os.ReadFile(filepath.Join(outputDir, path))
+		if os.IsNotExist(err) {
+			writes[path] = markRegion(path, content)
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		existing := string(data)
+
+		replaced, ok, err := replaceRegion(path, existing, content)
+		if err != nil {
+			return nil, nil, err
+		}
+		if ok {
+			if replaced != existing {
+				writes[path] = replaced
+			}
+			continue
+		}
+		if existing == content {
+			continue
+		}
+
+		switch strategy {
+		case collisionError:
+			clashes = append(clashes, path)
+		case collisionSkip:
+			fmt.Fprintf(log, "skipped %s: no generated region\n", path)
+		case collisionRename:
+			writes[path+renameSuffix] = markRegion(path, content)
+		case collisionMerge:
+			merged, err := mergeContent(path, existing, content)
+			if err != nil {
+				return nil, nil, err
+			}
+			writes[path] = merged
+		}
+	}
+	return writes, clashes, nil
+}
+
+// sortedKeys returns m's keys in lexical order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// renderGoModTemplate reads go.mod.tmpl and returns it with the target
 // module path substituted for `BLUEPRINT_TARGET_MODULE`.
-func renderGoModTemplate(tmplPath, outputDir, targetModule string) error {
+func renderGoModTemplate(tmplPath, targetModule string) (string, error) {
 	data, err := os.ReadFile(tmplPath)
 	if err != nil {
-		return err
+		return "", err
 	}
-	rendered := strings.ReplaceAll(string(data), "BLUEPRINT_TARGET_MODULE", targetModule)
-	return writeFile(outputDir, "go.mod", rendered)
+	return strings.ReplaceAll(string(data), "BLUEPRINT_TARGET_MODULE", targetModule), nil
 }
 
 // debugDump writes a summary of extracted blocks to w (used by --verbose).
 func debugDump(w io.Writer, blocks map[string]string) {
-	keys := make([]string, 0, len(blocks))
-	for k := range blocks {
-		keys = append(keys, k)
-	}
-	sort.Strings(keys)
-	for _, k := range keys {
+	for _, k := range sortedKeys(blocks) {
 		fmt.Fprintf(w, "  %s (%d bytes)\n", k, len(blocks[k]))
 	}
 }
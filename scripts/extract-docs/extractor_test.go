@@ -153,3 +153,225 @@ func keys(m map[string]string) []string {
 	}
 	return out
 }
+
+func TestReplaceRegion(t *testing.T) {
+	existing := "// hand-written header\n\n" + markRegion("x.go", "package old") + "\nfunc extra() {}\n"
+
+	got, ok, err := replaceRegion("x.go", existing, "package new")
+	if err != nil || !ok {
+		t.Fatalf("replaceRegion = (%t, %v), want (true, nil)", ok, err)
+	}
+	want := "// hand-written header\n\n" + markRegion("x.go", "package new") + "\nfunc extra() {}\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if _, ok, err := replaceRegion("x.go", "package plain\n", "package new"); ok || err != nil {
+		t.Errorf("file without region: got (%t, %v), want (false, nil)", ok, err)
+	}
+	if _, _, err := replaceRegion("x.go", "// "+regionBegin+"\npackage x\n", "package new"); err == nil {
+		t.Error("expected error for unterminated region, got nil")
+	}
+	if _, _, err := replaceRegion("x.go", existing+existing, "package new"); err == nil {
+		t.Error("expected error for repeated region, got nil")
+	}
+}
+
+func TestCommentPrefix(t *testing.T) {
+	cases := map[string]string{
+		"internal/api/handler.go":     "//",
+		"queries/products.sql":        "--",
+		"Makefile":                    "#",
+		".env.example":                "#",
+		".github/workflows/ci.yml":    "#",
+		"go.mod":                      "",
+		"README.md":                   "",
+		"internal/api/handler.go.new": "//",
+		"schema.sql.new":              "--",
+	}
+	for path, want := range cases {
+		if got := commentPrefix(path); got != want {
+			t.Errorf("commentPrefix(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestRun_TemplateCollisions(t *testing.T) {
+	templates := t.TempDir()
+	if err := writeFile(templates, "internal/api/handler.go", "package template"); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFile(templates, "schema.sql", "CREATE TABLE accounts (id UUID);"); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		strategy string
+		wantErr  bool
+		files    map[string]string // path -> substring the file must contain
+	}{
+		{collisionError, true, nil},
+		{collisionSkip, false, map[string]string{"internal/api/handler.go": "package api"}},
+		{collisionRename, false, map[string]string{
+			"internal/api/handler.go":     "package api",
+			"internal/api/handler.go.new": "package template",
+			"schema.sql.new":              "CREATE TABLE accounts",
+		}},
+		// Go files can't be merged, so merge refuses the whole run.
+		{collisionMerge, true, nil},
+	}
+	for _, c := range cases {
+		t.Run(c.strategy, func(t *testing.T) {
+			output := filepath.Join(t.TempDir(), "out")
+			err := run([]string{testdataDoc}, t.TempDir(), templates, "github.com/example/smoketest", output, c.strategy, false, false, false)
+			if c.wantErr {
+				if err == nil {
+					t.Fatal("expected collision error, got nil")
+				}
+				if _, statErr := os.Stat(output); !os.IsNotExist(statErr) {
+					t.Errorf("output directory created despite collision error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("run: %v", err)
+			}
+			for path, want := range c.files {
+				data, err := os.ReadFile(filepath.Join(output, path))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !strings.Contains(string(data), want) {
+					t.Errorf("%s = %q, want it to contain %q", path, data, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRun_MergeTemplateIntoSQL(t *testing.T) {
+	templates := t.TempDir()
+	if err := writeFile(templates, "schema.sql", "CREATE TABLE accounts (id UUID);"); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(t.TempDir(), "out")
+	if err := run([]string{testdataDoc}, t.TempDir(), templates, "github.com/example/smoketest", output, collisionMerge, false, false, false); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(output, "schema.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(data)
+	if !strings.Contains(got, "CREATE TABLE products") || !strings.Contains(got, "CREATE TABLE accounts") {
+		t.Errorf("schema.sql = %q, want both tables", got)
+	}
+}
+
+func TestRun_ExistingOutput(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "out")
+	if err := os.MkdirAll(output, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := run([]string{testdataDoc}, t.TempDir(), t.TempDir(), "github.com/example/smoketest", output, collisionError, false, false, false); err == nil {
+		t.Fatal("expected error for existing output without --clean or --force, got nil")
+	}
+}
+
+func TestRun_ForceRewritesOnlyGeneratedRegions(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "out")
+	extract := func(doc string, force bool, strategy string) error {
+		return run([]string{doc}, t.TempDir(), t.TempDir(), "github.com/example/smoketest", output, strategy, false, force, false)
+	}
+	if err := extract(testdataDoc, false, collisionError); err != nil {
+		t.Fatalf("initial run: %v", err)
+	}
+
+	// Hand-edit outside the generated region, and replace one file outright.
+	productPath := filepath.Join(output, "internal/models/product.go")
+	data, err := os.ReadFile(productPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const handWritten = "\nfunc (p Product) Label() string { return p.ID }\n"
+	if err := os.WriteFile(productPath, append(data, handWritten...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	handlerPath := filepath.Join(output, "internal/api/handler.go")
+	if err := os.WriteFile(handlerPath, []byte("package api\n\n// rewritten by hand\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The field list evolves in the doc.
+	original, err := os.ReadFile(testdataDoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	evolved := strings.Replace(string(original), "type Product struct {\n    ID string\n}", "type Product struct {\n    ID   string\n    Name string\n}", 1)
+	doc := filepath.Join(t.TempDir(), "evolved.md")
+	if err := os.WriteFile(doc, []byte(evolved), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := extract(doc, true, collisionError); err == nil {
+		t.Fatal("expected collision error for hand-written handler.go, got nil")
+	}
+	if err := extract(doc, true, collisionSkip); err != nil {
+		t.Fatalf("regenerate: %v", err)
+	}
+
+	data, err = os.ReadFile(productPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data); !strings.Contains(got, "Name string") || !strings.HasSuffix(got, handWritten) {
+		t.Errorf("product.go = %q, want the new field and the hand-written method kept", got)
+	}
+	data, err = os.ReadFile(handlerPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data); !strings.Contains(got, "rewritten by hand") {
+		t.Errorf("handler.go = %q, want the hand-written file skipped", got)
+	}
+}
+
+// TestRun_RenameMarksBothCollisionKinds pins that a <path>.new file carries a
+// generated region whether it came from a doc/template clash or from a
+// hand-written file under --force, so the two are diffed the same way.
+func TestRun_RenameMarksBothCollisionKinds(t *testing.T) {
+	const module = "github.com/example/smoketest"
+	templates := t.TempDir()
+	if err := writeFile(templates, "schema.sql", "CREATE TABLE accounts (id UUID);"); err != nil {
+		t.Fatal(err)
+	}
+	fromTemplate := filepath.Join(t.TempDir(), "out")
+	if err := run([]string{testdataDoc}, t.TempDir(), templates, module, fromTemplate, collisionRename, false, false, false); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	fromHand := filepath.Join(t.TempDir(), "out")
+	if err := run([]string{testdataDoc}, t.TempDir(), t.TempDir(), module, fromHand, collisionError, false, false, false); err != nil {
+		t.Fatalf("initial run: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(fromHand, "schema.sql"), []byte("-- by hand\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := run([]string{testdataDoc}, t.TempDir(), t.TempDir(), module, fromHand, collisionRename, false, true, false); err != nil {
+		t.Fatalf("regenerate: %v", err)
+	}
+
+	for _, c := range []struct{ dir, want string }{
+		{fromTemplate, "CREATE TABLE accounts"},
+		{fromHand, "CREATE TABLE products"},
+	} {
+		data, err := os.ReadFile(filepath.Join(c.dir, "schema.sql.new"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := string(data)
+		if !strings.HasPrefix(got, "-- "+regionBegin+"\n") || !strings.HasSuffix(got, "-- "+regionEnd+"\n") || !strings.Contains(got, c.want) {
+			t.Errorf("schema.sql.new = %q, want %q inside a generated region", got, c.want)
+		}
+	}
+}
@@ -13,9 +13,11 @@ func main() {
 		fixturesDir  = flag.String("fixtures", "examples/_smoke-fixtures", "directory containing go.mod.tmpl and other un-doc'd fixtures")
 		templatesDir = flag.String("templates", "templates", "directory of templates/* to merge into the smoke output")
 		module       = flag.String("module", "github.com/example/smoketest", "target module path (replaces github.com/yourorg/myapp in extracted content)")
-		output       = flag.String("output", "", "output directory (will be created; refused if it exists unless --force)")
-		force        = flag.Bool("force", false, "remove existing output directory before extracting")
+		output       = flag.String("output", "", "output directory (will be created; refused if it exists unless --clean or --force)")
+		clean        = flag.Bool("clean", false, "remove existing output directory before extracting")
+		force        = flag.Bool("force", false, "regenerate into an existing output directory, rewriting only generated regions")
 		verbose      = flag.Bool("verbose", false, "print summary of extracted files")
+		collisions   = flag.String("collisions", collisionError, "when two sources want the same path: error | skip | rename | merge")
 	)
 	flag.Parse()
 
@@ -28,57 +30,94 @@ func main() {
 		os.Exit(2)
 	}
 
+	if !validCollisionStrategy(*collisions) {
+		fmt.Fprintf(os.Stderr, "--collisions must be one of: %s, %s, %s, %s\n", collisionError, collisionSkip, collisionRename, collisionMerge)
+		os.Exit(2)
+	}
+
 	docs := strings.Split(*docsArg, ",")
 	for i := range docs {
 		docs[i] = strings.TrimSpace(docs[i])
 	}
 
-	if err := run(docs, *fixturesDir, *templatesDir, *module, *output, *force, *verbose); err != nil {
+	if err := run(docs, *fixturesDir, *templatesDir, *module, *output, *collisions, *clean, *force, *verbose); err != nil {
 		fmt.Fprintln(os.Stderr, "error:", err)
 		os.Exit(1)
 	}
 }
 
-func run(docs []string, fixturesDir, templatesDir, module, output string, force, verbose bool) error {
-	if _, err := os.Stat(output); err == nil {
-		if !force {
-			return fmt.Errorf("output directory %q exists (pass --force to overwrite)", output)
-		}
-		if err := os.RemoveAll(output); err != nil {
-			return fmt.Errorf("removing existing output: %w", err)
-		}
-	} else if !os.IsNotExist(err) {
-		return fmt.Errorf("checking output: %w", err)
-	}
-	if err := os.MkdirAll(output, 0o755); err != nil {
-		return fmt.Errorf("creating output: %w", err)
-	}
-
+func run(docs []string, fixturesDir, templatesDir, module, output, collisions string, clean, force, verbose bool) error {
 	blocks, err := extractFromDocs(docs)
 	if err != nil {
 		return fmt.Errorf("extracting docs: %w", err)
 	}
+
+	files := make(map[string]string, len(blocks))
 	for path, content := range blocks {
 		out := stripLeadingPathComment(content, path)
-		out = substituteModulePath(out, module)
-		if err := writeFile(output, path, out); err != nil {
-			return fmt.Errorf("writing %s: %w", path, err)
-		}
+		files[path] = substituteModulePath(out, module)
 	}
 
-	if err := copyTreeWithSubstitution(templatesDir, output, module); err != nil {
-		return fmt.Errorf("copying templates: %w", err)
+	templates, err := readTree(templatesDir, module)
+	if err != nil {
+		return fmt.Errorf("reading templates: %w", err)
 	}
-
 	tmpl := fixturesDir + "/go.mod.tmpl"
 	if _, err := os.Stat(tmpl); err == nil {
-		if err := renderGoModTemplate(tmpl, output, module); err != nil {
+		gomod, err := renderGoModTemplate(tmpl, module)
+		if err != nil {
 			return fmt.Errorf("rendering go.mod.tmpl: %w", err)
 		}
+		templates["go.mod"] = gomod
+	}
+
+	// Resolve every collision before touching the output directory so a
+	// refusal leaves the previous smoke tree intact.
+	clashes, err := resolveTemplates(files, templates, collisions)
+	if err != nil {
+		return fmt.Errorf("merging templates: %w", err)
+	}
+	if len(clashes) > 0 {
+		return fmt.Errorf("templates and docs both define %s (pass --collisions=skip, rename, or merge)", strings.Join(clashes, ", "))
+	}
+
+	writes := make(map[string]string, len(files))
+	_, statErr := os.Stat(output)
+	switch {
+	case statErr == nil && clean:
+		if err := os.RemoveAll(output); err != nil {
+			return fmt.Errorf("removing existing output: %w", err)
+		}
+		fallthrough
+	case os.IsNotExist(statErr):
+		for path, content := range files {
+			writes[path] = markRegion(path, content)
+		}
+	case statErr == nil && force:
+		writes, clashes, err = regenerate(output, files, collisions, os.Stdout)
+		if err != nil {
+			return fmt.Errorf("regenerating %s: %w", output, err)
+		}
+		if len(clashes) > 0 {
+			return fmt.Errorf("%s already exist without a generated region (pass --collisions=skip, rename, or merge)", strings.Join(clashes, ", "))
+		}
+	case statErr == nil:
+		return fmt.Errorf("output directory %q exists (pass --clean to recreate it or --force to regenerate in place)", output)
+	default:
+		return fmt.Errorf("checking output: %w", statErr)
+	}
+
+	if err := os.MkdirAll(output, 0o755); err != nil {
+		return fmt.Errorf("creating output: %w", err)
+	}
+	for _, path := range sortedKeys(writes) {
+		if err := writeFile(output, path, writes[path]); err != nil {
+			return fmt.Errorf("writing %s: %w", path, err)
+		}
 	}
 
 	if verbose {
-		fmt.Printf("Extracted %d files from %d docs:\n", len(blocks), len(docs))
+		fmt.Printf("Extracted %d files from %d docs, wrote %d:\n", len(blocks), len(docs), len(writes))
 		debugDump(os.Stdout, blocks)
 	}
 	return nil